@@ -0,0 +1,78 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package health
+
+import (
+	"fmt"
+
+	"github.com/youtube/vitess/go/jscfg"
+)
+
+// ReporterConfig describes one Reporter entry in a health reporters
+// config file. Kind selects which built-in Reporter implementation to
+// instantiate; Command, Path and MinFreeBytes are only read by the
+// Kind they apply to.
+type ReporterConfig struct {
+	Name      string
+	Kind      ReporterKind
+	Weight    int
+	Threshold int    // consecutive bad checks before this reporter counts, see Chain.Register
+	Command   string `json:",omitempty"` // shell command for ReporterKindExternal
+
+	Path         string `json:",omitempty"` // filesystem path to check, for ReporterKindDiskFull
+	MinFreeBytes int64  `json:",omitempty"` // Unhealthy below this, Degraded below 2x this, for ReporterKindDiskFull
+
+	Policy Policy `json:",omitempty"` // only read from the top-level config, see LoadConfig
+}
+
+// ReporterKind selects a built-in Reporter implementation.
+type ReporterKind string
+
+const (
+	// ReporterKindReplicationLag reports on slave replication delay.
+	ReporterKindReplicationLag ReporterKind = "replication_lag"
+	// ReporterKindDiskFull reports on available disk space.
+	ReporterKindDiskFull ReporterKind = "disk_full"
+	// ReporterKindSemiSync reports on MySQL semi-sync ack state.
+	ReporterKindSemiSync ReporterKind = "semi_sync"
+	// ReporterKindExternal runs an external command and maps its exit
+	// code to a Severity (0 -> OK, 1 -> Degraded, anything else ->
+	// Unhealthy), for custom user probes.
+	ReporterKindExternal ReporterKind = "external"
+)
+
+// Config is the top-level shape of a health reporters config file.
+type Config struct {
+	Policy    Policy
+	Reporters []ReporterConfig
+}
+
+// LoadConfig reads a JSON health reporters config file and returns a
+// Chain built from it, using factory to create each Reporter. An empty
+// path returns an empty Chain using PolicyMax, matching the
+// pre-config-file default.
+func LoadConfig(path string, factory ReporterFactory) (*Chain, error) {
+	if path == "" {
+		return NewChain(PolicyMax), nil
+	}
+	var cfg Config
+	if err := jscfg.ReadJson(path, &cfg); err != nil {
+		return nil, fmt.Errorf("can't read health reporters config %v: %v", path, err)
+	}
+	chain := NewChain(cfg.Policy)
+	for _, rc := range cfg.Reporters {
+		reporter, err := factory(rc)
+		if err != nil {
+			return nil, fmt.Errorf("can't create health reporter %v: %v", rc.Name, err)
+		}
+		chain.Register(rc.Name, rc.Weight, rc.Threshold, reporter)
+	}
+	return chain, nil
+}
+
+// ReporterFactory builds a Reporter from its config entry. Callers
+// supply one so this package doesn't need to depend on mysqlctl,
+// tabletserver, etc. to know how to build the built-in reporter kinds.
+type ReporterFactory func(ReporterConfig) (Reporter, error)
@@ -0,0 +1,265 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package health defines a pluggable interface for tablet health
+// reporting. A tablet can register any number of named Reporters
+// (replication lag, disk space, custom probes, ...); a Chain combines
+// their individual verdicts into a single tablet-wide status according
+// to a configurable Policy.
+package health
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/golang/glog"
+)
+
+// Severity describes how bad a single Reporter's verdict is.
+type Severity int
+
+const (
+	// SeverityOK means the reporter sees nothing wrong.
+	SeverityOK Severity = iota
+	// SeverityDegraded means the reporter sees a problem that
+	// shouldn't yet cause the tablet to be taken out of rotation.
+	SeverityDegraded
+	// SeverityUnhealthy means the reporter wants the tablet taken
+	// out of rotation.
+	SeverityUnhealthy
+)
+
+// String returns a human readable name for the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "OK"
+	case SeverityDegraded:
+		return "Degraded"
+	case SeverityUnhealthy:
+		return "Unhealthy"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Report is the verdict returned by a single Reporter.
+type Report struct {
+	// Severity is how bad things are according to this reporter.
+	Severity Severity
+	// Message is a short human readable explanation, used for
+	// display in vtctld and in logs. It may be empty when Severity
+	// is SeverityOK.
+	Message string
+}
+
+// Reporter is implemented by anything that can report on one aspect of
+// a tablet's health (replication lag, disk usage, a custom user probe,
+// ...). Check is called periodically by a Chain and should return
+// quickly; long-running probes should keep their own background state
+// and have Check just read it.
+type Reporter interface {
+	Check() (Report, error)
+}
+
+// ReporterFunc adapts a plain function to the Reporter interface.
+type ReporterFunc func() (Report, error)
+
+// Check implements Reporter.
+func (f ReporterFunc) Check() (Report, error) {
+	return f()
+}
+
+// Policy decides how the Reports from every registered Reporter are
+// combined into a single Severity for the whole tablet.
+type Policy int
+
+const (
+	// PolicyMax takes the worst severity across all reporters.
+	PolicyMax Policy = iota
+	// PolicyWeightedSum sums each reporter's (weight * severity),
+	// divides by the total weight (rounding down; a weight of 0 counts
+	// as 1) and buckets that average back into a Severity, so a handful
+	// of low-weight Degraded reporters can't outweigh one important one.
+	PolicyWeightedSum
+	// PolicyQuorum goes Unhealthy only once a majority (by weight) of
+	// reporters report Unhealthy; otherwise it reports the max of the
+	// remaining severities.
+	PolicyQuorum
+)
+
+// entry is the bookkeeping a Chain keeps for one registered Reporter.
+type entry struct {
+	reporter  Reporter
+	weight    int
+	threshold int // consecutive bad checks required before this entry counts as bad
+
+	lastSeverity   Severity
+	lastMessage    string
+	consecutiveBad int // consecutive checks that were worse than SeverityOK
+}
+
+// State is the externally visible status of one registered Reporter,
+// used for display (e.g. in HealthStreamReply) and for tests.
+type State struct {
+	Name     string
+	Severity Severity
+	Message  string
+	Weight   int
+}
+
+// Chain combines any number of named Reporters into a single tablet
+// health verdict, using a configurable Policy. Reporters can be added
+// and removed while the Chain is in use: all methods are safe for
+// concurrent use.
+//
+// To avoid flapping the tablet type under transient load, a Reporter's
+// bad verdict only counts towards the combined result once it has been
+// seen for 'threshold' consecutive Run calls (its hysteresis window).
+// A good verdict always clears the counter immediately.
+type Chain struct {
+	mu      sync.Mutex
+	policy  Policy
+	entries map[string]*entry
+}
+
+// NewChain returns an empty Chain using the given Policy.
+func NewChain(policy Policy) *Chain {
+	return &Chain{
+		policy:  policy,
+		entries: make(map[string]*entry),
+	}
+}
+
+// SetPolicy changes the combining policy used by subsequent Run calls.
+func (c *Chain) SetPolicy(policy Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+}
+
+// Register adds or replaces a named Reporter. weight is its
+// contribution under PolicyWeightedSum and PolicyQuorum; threshold is
+// the number of consecutive bad Check results required before this
+// reporter's verdict is allowed to affect the combined Severity.
+func (c *Chain) Register(name string, weight, threshold int, r Reporter) {
+	if threshold < 1 {
+		threshold = 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[name] = &entry{
+		reporter:  r,
+		weight:    weight,
+		threshold: threshold,
+	}
+}
+
+// Unregister removes a named Reporter, if present.
+func (c *Chain) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// Run checks every registered Reporter, applies hysteresis, combines
+// the results according to the Chain's Policy, and returns the
+// combined Severity along with the per-reporter state (for display and
+// persistence). A Reporter whose Check returns an error is treated as
+// SeverityUnhealthy with the error as its message.
+func (c *Chain) Run() (Severity, map[string]State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make(map[string]State, len(c.entries))
+	effective := make(map[string]Severity, len(c.entries))
+	for name, e := range c.entries {
+		report, err := e.reporter.Check()
+		if err != nil {
+			report = Report{Severity: SeverityUnhealthy, Message: err.Error()}
+			log.Warningf("health: reporter %v failed: %v", name, err)
+		}
+		e.lastSeverity = report.Severity
+		e.lastMessage = report.Message
+		if report.Severity == SeverityOK {
+			e.consecutiveBad = 0
+		} else {
+			e.consecutiveBad++
+		}
+
+		// Hysteresis: only let a bad verdict count once it has
+		// persisted for 'threshold' consecutive checks.
+		effectiveSeverity := SeverityOK
+		if e.consecutiveBad >= e.threshold {
+			effectiveSeverity = report.Severity
+		}
+		effective[name] = effectiveSeverity
+
+		states[name] = State{
+			Name:     name,
+			Severity: effectiveSeverity,
+			Message:  report.Message,
+			Weight:   e.weight,
+		}
+	}
+
+	return c.combine(effective), states
+}
+
+func (c *Chain) combine(effective map[string]Severity) Severity {
+	if len(effective) == 0 {
+		return SeverityOK
+	}
+	switch c.policy {
+	case PolicyWeightedSum:
+		var sum, totalWeight int
+		for name, sev := range effective {
+			w := c.entries[name].weight
+			if w == 0 {
+				w = 1
+			}
+			sum += w * int(sev)
+			totalWeight += w
+		}
+		if totalWeight == 0 {
+			return SeverityOK
+		}
+		avg := sum / totalWeight
+		switch {
+		case avg >= int(SeverityUnhealthy):
+			return SeverityUnhealthy
+		case avg >= int(SeverityDegraded):
+			return SeverityDegraded
+		default:
+			return SeverityOK
+		}
+	case PolicyQuorum:
+		var unhealthyWeight, totalWeight int
+		worstOther := SeverityOK
+		for name, sev := range effective {
+			w := c.entries[name].weight
+			if w == 0 {
+				w = 1
+			}
+			totalWeight += w
+			if sev == SeverityUnhealthy {
+				unhealthyWeight += w
+			} else if sev > worstOther {
+				worstOther = sev
+			}
+		}
+		if totalWeight > 0 && unhealthyWeight*2 > totalWeight {
+			return SeverityUnhealthy
+		}
+		return worstOther
+	default: // PolicyMax
+		worst := SeverityOK
+		for _, sev := range effective {
+			if sev > worst {
+				worst = sev
+			}
+		}
+		return worst
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package actionnode
+
+import (
+	"time"
+
+	"github.com/youtube/vitess/go/vt/health"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// HealthStreamReply is streamed by the agent's health RPC to report on
+// the liveness and current state of a tablet, so vtgate and vtctld can
+// react to changes without polling the topology server.
+type HealthStreamReply struct {
+	Tablet              *topo.Tablet
+	BinlogPlayerMapSize int64
+
+	// HealthError is the last error from the health check, if any.
+	// Empty when the tablet is healthy.
+	HealthError string
+
+	// ReplicationDelay is the last known replication lag.
+	ReplicationDelay time.Duration
+
+	// ReporterStates carries, for each registered health.Reporter,
+	// its own post-hysteresis severity. This lets vtctld show
+	// which probe caused a demotion instead of just the final verdict.
+	ReporterStates map[string]health.State
+
+	// Draining is set while the tablet is in Drain mode: it is still
+	// serving in-flight transactions but should not be sent new ones
+	// and should not be picked as a reparent target.
+	Draining bool
+}
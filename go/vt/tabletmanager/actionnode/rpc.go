@@ -0,0 +1,55 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package actionnode
+
+import "time"
+
+// This file groups the request/response wire types for the
+// tabletmanager RPCs implemented directly on ActionAgent (Drain,
+// PromoteSlave, TabletExternallyReparented, ReloadSchemaOverrides and
+// ReloadHealthReporters). See rpc_server.go for the TabletManager
+// service that exposes them.
+
+// DrainArgs is the payload for the TabletManager.Drain RPC.
+type DrainArgs struct {
+	// Timeout bounds how long Drain waits for in-flight transactions
+	// to finish before tearing down the tablet's services anyway.
+	Timeout time.Duration
+}
+
+// DrainReply is the (empty) response for the TabletManager.Drain RPC.
+type DrainReply struct{}
+
+// PromoteSlaveArgs is the (empty) payload for the
+// TabletManager.PromoteSlave RPC.
+type PromoteSlaveArgs struct{}
+
+// PromoteSlaveReply is the (empty) response for the
+// TabletManager.PromoteSlave RPC.
+type PromoteSlaveReply struct{}
+
+// TabletExternallyReparentedArgs is the (empty) payload for the
+// TabletManager.TabletExternallyReparented RPC.
+type TabletExternallyReparentedArgs struct{}
+
+// TabletExternallyReparentedReply is the (empty) response for the
+// TabletManager.TabletExternallyReparented RPC.
+type TabletExternallyReparentedReply struct{}
+
+// ReloadSchemaOverridesArgs is the (empty) payload for the
+// TabletManager.ReloadSchemaOverrides RPC.
+type ReloadSchemaOverridesArgs struct{}
+
+// ReloadSchemaOverridesReply is the (empty) response for the
+// TabletManager.ReloadSchemaOverrides RPC.
+type ReloadSchemaOverridesReply struct{}
+
+// ReloadHealthReportersArgs is the (empty) payload for the
+// TabletManager.ReloadHealthReporters RPC.
+type ReloadHealthReportersArgs struct{}
+
+// ReloadHealthReportersReply is the (empty) response for the
+// TabletManager.ReloadHealthReporters RPC.
+type ReloadHealthReportersReply struct{}
@@ -26,10 +26,16 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/context"
+	"gopkg.in/fsnotify.v1"
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/history"
@@ -49,19 +55,24 @@ var (
 	tabletHostname = flag.String("tablet_hostname", "", "if not empty, this hostname will be assumed instead of trying to resolve it")
 
 	_ = flag.String("vtaction_binary_path", "", "(DEPRECATED) Full path (including filename) to vtaction binary. If not set, tries VTROOT/bin/vtaction.")
+
+	healthReportersFile = flag.String("health_reporters_config", "", "if not empty, path to a JSON file describing the health.Reporter chain to use (replication lag, disk full, semi-sync, custom probes, ...)")
+
+	replicationLagDegradedSeconds  = flag.Int("health_replication_lag_degraded", 30, "replication delay, in seconds, above which the built-in replication_lag health reporter reports Degraded")
+	replicationLagUnhealthySeconds = flag.Int("health_replication_lag_unhealthy", 300, "replication delay, in seconds, above which the built-in replication_lag health reporter reports Unhealthy")
+
+	healthCheckInterval = flag.Duration("health_check_interval", 20*time.Second, "how often to run the health.Chain and broadcast its result")
 )
 
 // ActionAgent is the main class for the agent.
 type ActionAgent struct {
 	// The following fields are set during creation
 	QueryServiceControl tabletserver.QueryServiceControl
-	HealthReporter      health.Reporter
 	TopoServer          topo.Server
 	TabletAlias         topo.TabletAlias
 	Mysqld              *mysqlctl.Mysqld
 	MysqlDaemon         mysqlctl.MysqlDaemon
 	DBConfigs           *dbconfigs.DBConfigs
-	SchemaOverrides     []tabletserver.SchemaOverride
 	BinlogPlayerMap     *BinlogPlayerMap
 	LockTimeout         time.Duration
 	// batchCtx is given to the agent by its creator, and should be used for
@@ -87,6 +98,11 @@ type ActionAgent struct {
 	_tabletControl   *topo.TabletControl
 	_waitingForMysql bool
 
+	// _draining is true from the start of Drain until the tablet
+	// process exits. While draining, the tablet must not be chosen as
+	// a reparent target.
+	_draining bool
+
 	// if the agent is healthy, this is nil. Otherwise it contains
 	// the reason we're not healthy.
 	_healthy error
@@ -95,23 +111,407 @@ type ActionAgent struct {
 	_replicationDelay time.Duration
 
 	// healthStreamMutex protects all the following fields
-	healthStreamMutex sync.Mutex
-	healthStreamIndex int
-	healthStreamMap   map[int]chan<- *actionnode.HealthStreamReply
+	healthStreamMutex  sync.Mutex
+	healthStreamIndex  int
+	healthStreamMap    map[int]*healthStreamSubscriber
+	healthStreamSeq    int64
+	healthStreamBuffer []healthStreamEntry
+
+	// healthReportersMutex protects healthReporters and
+	// healthReporterStates, so ReloadHealthReporters can swap the
+	// chain in atomically while a check is in flight.
+	healthReportersMutex sync.Mutex
+	healthReporters      *health.Chain
+	healthReporterStates map[string]health.State
+
+	// healthStreamDroppedCounts counts, per subscriber id, how many
+	// HealthStreamReply messages were dropped because that subscriber
+	// was too slow to keep up.
+	healthStreamDroppedCounts *stats.Counters
+
+	// schemaOverridesFile is the path ReloadSchemaOverrides re-reads;
+	// empty if the agent wasn't started with one.
+	schemaOverridesFile string
+
+	// schemaOverridesMutex serializes writers to SchemaOverrides (an
+	// explicit ReloadSchemaOverrides call racing the fsnotify-driven
+	// one). SchemaOverrides itself stays a plain exported field, like
+	// before this package grew a reload path, so existing callers that
+	// read it directly (e.g. at AllowQueries startup) keep working
+	// unchanged; a reader racing a reload can see either the old or the
+	// new slice, never a torn one, since the field is only ever
+	// replaced wholesale, never mutated in place.
+	schemaOverridesMutex sync.Mutex
+	SchemaOverrides      []tabletserver.SchemaOverride
+}
+
+// healthStreamHistorySize bounds how many past HealthStreamReply
+// messages the agent keeps around for subscribers to replay from on
+// reconnect.
+const healthStreamHistorySize = 20
+
+// healthStreamEntry is one sequence-numbered entry in the replay
+// buffer.
+type healthStreamEntry struct {
+	seq   int64
+	reply *actionnode.HealthStreamReply
+}
+
+// HealthStreamSlowConsumerPolicy controls what a subscriber's channel
+// does when it can't keep up with the broadcast rate.
+type HealthStreamSlowConsumerPolicy int
+
+const (
+	// HealthStreamDropOldest discards the oldest queued reply to make
+	// room for the new one.
+	HealthStreamDropOldest HealthStreamSlowConsumerPolicy = iota
+	// HealthStreamCoalesce drops the new reply when it is equivalent to
+	// the last queued one (no type or health transition, see
+	// healthStreamReplyEquivalent), so a burst of identical replies only
+	// costs one slot.
+	HealthStreamCoalesce
+	// HealthStreamDisconnect unsubscribes the consumer once it has
+	// been unable to accept a new reply for its configured deadline.
+	HealthStreamDisconnect
+)
+
+// healthStreamSubscriber tracks the per-subscriber state needed to
+// apply its slow-consumer policy.
+type healthStreamSubscriber struct {
+	id       int
+	ch       chan *actionnode.HealthStreamReply
+	policy   HealthStreamSlowConsumerPolicy
+	deadline time.Duration
+
+	// blockedSince is when ch first failed to accept a reply, for the
+	// HealthStreamDisconnect policy. Reset to zero once a send
+	// succeeds.
+	blockedSince time.Time
+
+	// lastEnqueued is the most recent reply actually placed in ch
+	// (i.e. the current tail of the queue), used by HealthStreamCoalesce
+	// to detect a run of equivalent replies. A channel only supports
+	// FIFO reads, so this is tracked separately rather than by peeking
+	// at ch itself.
+	lastEnqueued *actionnode.HealthStreamReply
+}
+
+// healthStreamReplyEquivalent returns true if two replies carry the
+// same tablet type and health state (HealthError, ReplicationDelay and
+// Draining), for HealthStreamCoalesce. ReporterStates are not compared.
+func healthStreamReplyEquivalent(a, b *actionnode.HealthStreamReply) bool {
+	if (a.Tablet == nil) != (b.Tablet == nil) {
+		return false
+	}
+	if a.Tablet != nil && a.Tablet.Type != b.Tablet.Type {
+		return false
+	}
+	return a.HealthError == b.HealthError && a.ReplicationDelay == b.ReplicationDelay && a.Draining == b.Draining
+}
+
+// healthReporterFactory builds the built-in health.Reporter kinds
+// (replication lag, disk full, semi-sync, external command) from a
+// health.ReporterConfig entry.
+func (agent *ActionAgent) healthReporterFactory(cfg health.ReporterConfig) (health.Reporter, error) {
+	switch cfg.Kind {
+	case health.ReporterKindReplicationLag:
+		return health.ReporterFunc(func() (health.Report, error) {
+			delay, err := agent.MysqlDaemon.SlaveStatus()
+			if err != nil {
+				return health.Report{}, err
+			}
+			if int(delay.SecondsBehindMaster) > *replicationLagUnhealthySeconds {
+				return health.Report{Severity: health.SeverityUnhealthy, Message: fmt.Sprintf("replication delay %vs", delay.SecondsBehindMaster)}, nil
+			}
+			if int(delay.SecondsBehindMaster) > *replicationLagDegradedSeconds {
+				return health.Report{Severity: health.SeverityDegraded, Message: fmt.Sprintf("replication delay %vs", delay.SecondsBehindMaster)}, nil
+			}
+			return health.Report{Severity: health.SeverityOK}, nil
+		}), nil
+	case health.ReporterKindDiskFull:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("reporter %v: disk_full requires a Path", cfg.Name)
+		}
+		return health.ReporterFunc(func() (health.Report, error) {
+			var stat syscall.Statfs_t
+			if err := syscall.Statfs(cfg.Path, &stat); err != nil {
+				return health.Report{}, fmt.Errorf("can't stat %v: %v", cfg.Path, err)
+			}
+			free := int64(stat.Bavail) * int64(stat.Bsize)
+			switch {
+			case free < cfg.MinFreeBytes:
+				return health.Report{Severity: health.SeverityUnhealthy, Message: fmt.Sprintf("only %v bytes free on %v, want at least %v", free, cfg.Path, cfg.MinFreeBytes)}, nil
+			case free < 2*cfg.MinFreeBytes:
+				return health.Report{Severity: health.SeverityDegraded, Message: fmt.Sprintf("only %v bytes free on %v", free, cfg.Path)}, nil
+			default:
+				return health.Report{Severity: health.SeverityOK}, nil
+			}
+		}), nil
+	case health.ReporterKindSemiSync:
+		return health.ReporterFunc(func() (health.Report, error) {
+			qr, err := agent.MysqlDaemon.FetchSuperQuery("SHOW STATUS LIKE 'Rpl_semi_sync_master_clients'")
+			if err != nil {
+				return health.Report{}, err
+			}
+			if len(qr.Rows) == 0 {
+				return health.Report{Severity: health.SeverityDegraded, Message: "semi-sync plugin not loaded"}, nil
+			}
+			clients, err := strconv.Atoi(qr.Rows[0][1].String())
+			if err != nil {
+				return health.Report{}, fmt.Errorf("can't parse Rpl_semi_sync_master_clients: %v", err)
+			}
+			if clients == 0 {
+				return health.Report{Severity: health.SeverityDegraded, Message: "no semi-sync replica is acking"}, nil
+			}
+			return health.Report{Severity: health.SeverityOK}, nil
+		}), nil
+	case health.ReporterKindExternal:
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("reporter %v: external requires a Command", cfg.Name)
+		}
+		return health.ReporterFunc(func() (health.Report, error) {
+			out, err := exec.Command("/bin/sh", "-c", cfg.Command).CombinedOutput()
+			if err == nil {
+				return health.Report{Severity: health.SeverityOK}, nil
+			}
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				return health.Report{}, fmt.Errorf("can't run probe command %q: %v", cfg.Command, err)
+			}
+			message := strings.TrimSpace(string(out))
+			status, ok := exitErr.Sys().(syscall.WaitStatus)
+			if ok && status.ExitStatus() == 1 {
+				return health.Report{Severity: health.SeverityDegraded, Message: message}, nil
+			}
+			return health.Report{Severity: health.SeverityUnhealthy, Message: message}, nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown health reporter kind %v", cfg.Kind)
+	}
+}
+
+// loadHealthReporters reads the health reporters config file (if any)
+// and returns the Chain it describes. A missing/empty path yields an
+// empty PolicyMax chain, so an agent with no config behaves as before.
+func (agent *ActionAgent) loadHealthReporters(path string) *health.Chain {
+	chain, err := health.LoadConfig(path, agent.healthReporterFactory)
+	if err != nil {
+		log.Warningf("can't load health reporters config %v, falling back to empty chain: %v", path, err)
+		return health.NewChain(health.PolicyMax)
+	}
+	return chain
+}
+
+// ReloadHealthReporters re-reads the health reporters config file and
+// atomically swaps in the new chain, so operators can add or retune
+// probes (e.g. a new disk_full threshold) without restarting the
+// agent. It is exposed as the TabletManager.ReloadHealthReporters RPC;
+// see rpc_server.go.
+func (agent *ActionAgent) ReloadHealthReporters(ctx context.Context) error {
+	chain := agent.loadHealthReporters(*healthReportersFile)
+	agent.healthReportersMutex.Lock()
+	agent.healthReporters = chain
+	agent.healthReportersMutex.Unlock()
+	log.Infof("Reloaded health reporters from %v", *healthReportersFile)
+	return nil
+}
+
+// runHealthReporters runs the current health.Chain, records the
+// per-reporter states (see HealthReporterStates), and returns them
+// along with the combined severity, for use by the health check loop
+// and for display in HealthStreamReply.
+func (agent *ActionAgent) runHealthReporters() (health.Severity, map[string]health.State) {
+	agent.healthReportersMutex.Lock()
+	chain := agent.healthReporters
+	agent.healthReportersMutex.Unlock()
+
+	severity, states := chain.Run()
+
+	agent.healthReportersMutex.Lock()
+	agent.healthReporterStates = states
+	agent.healthReportersMutex.Unlock()
+
+	return severity, states
+}
+
+// HealthReporterStates returns a copy of the last combined per-reporter
+// health states, for display in vtctld and for the health stream.
+func (agent *ActionAgent) HealthReporterStates() map[string]health.State {
+	agent.healthReportersMutex.Lock()
+	defer agent.healthReportersMutex.Unlock()
+	states := make(map[string]health.State, len(agent.healthReporterStates))
+	for name, s := range agent.healthReporterStates {
+		states[name] = s
+	}
+	return states
+}
+
+// runHealthCheck runs the health.Chain, records its combined verdict as
+// the agent's healthiness (see Healthy), and broadcasts a
+// HealthStreamReply carrying the per-reporter ReporterStates so
+// vtctld can show which probe, if any, caused a demotion. It also
+// carries the current Draining() state, so a Drain in progress stays
+// reflected on every tick of this loop instead of only on the one
+// broadcast Drain itself sends.
+func (agent *ActionAgent) runHealthCheck() {
+	severity, states := agent.runHealthReporters()
+
+	var healthErr error
+	if severity == health.SeverityUnhealthy {
+		healthErr = fmt.Errorf("health check reported Unhealthy, see ReporterStates for which probe(s) caused it")
+	}
+
+	agent.mutex.Lock()
+	agent._healthy = healthErr
+	replicationDelay := agent._replicationDelay
+	agent.mutex.Unlock()
+
+	tablet := agent.Tablet()
+	if tablet == nil {
+		return
+	}
+	hsr := &actionnode.HealthStreamReply{
+		Tablet:           tablet.Tablet,
+		ReplicationDelay: replicationDelay,
+		ReporterStates:   states,
+		Draining:         agent.Draining(),
+	}
+	if healthErr != nil {
+		hsr.HealthError = healthErr.Error()
+	}
+	agent.BroadcastHealthStreamReply(hsr)
+}
+
+// runHealthCheckLoop calls runHealthCheck on a fixed interval until ctx
+// is done. It is started once from initHeathCheck, and is the agent's
+// only writer of _healthy and only source of periodic health stream
+// broadcasts: having two independently-scheduled loops write the same
+// state would make Healthy() and the stream race and flap.
+func (agent *ActionAgent) runHealthCheckLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			agent.runHealthCheck()
+		}
+	}
+}
+
+// initHeathCheck starts the background loop that drives the agent's
+// health.Chain on health_check_interval and broadcasts its verdict. It
+// is the single authority for _healthy and for periodic health stream
+// updates.
+func (agent *ActionAgent) initHeathCheck() {
+	go agent.runHealthCheckLoop(agent.batchCtx, *healthCheckInterval)
 }
 
 func loadSchemaOverrides(overridesFile string) []tabletserver.SchemaOverride {
+	schemaOverrides, err := readSchemaOverrides(overridesFile)
+	if err != nil {
+		log.Warningf("can't read overridesFile %v: %v", overridesFile, err)
+		return nil
+	}
+	data, _ := json.MarshalIndent(schemaOverrides, "", "  ")
+	log.Infof("schemaOverrides: %s\n", data)
+	return schemaOverrides
+}
+
+// readSchemaOverrides reads and parses the schema overrides file,
+// without any of the logging loadSchemaOverrides does on the initial
+// load. Used directly by ReloadSchemaOverrides, which needs to return
+// a real error to its RPC caller instead of just logging a warning.
+func readSchemaOverrides(overridesFile string) ([]tabletserver.SchemaOverride, error) {
 	var schemaOverrides []tabletserver.SchemaOverride
 	if overridesFile == "" {
-		return schemaOverrides
+		return schemaOverrides, nil
 	}
 	if err := jscfg.ReadJson(overridesFile, &schemaOverrides); err != nil {
-		log.Warningf("can't read overridesFile %v: %v", overridesFile, err)
-	} else {
-		data, _ := json.MarshalIndent(schemaOverrides, "", "  ")
-		log.Infof("schemaOverrides: %s\n", data)
+		return nil, err
 	}
-	return schemaOverrides
+	return schemaOverrides, nil
+}
+
+// ReloadSchemaOverrides re-reads schemaOverridesFile, validates the
+// result against the tablet's current schema, and atomically swaps it
+// in, triggering the query service to refresh its plan cache for the
+// affected tables only. It is exposed as the
+// TabletManager.ReloadSchemaOverrides RPC (see rpc_server.go), and is
+// also called automatically when the overrides file changes on disk
+// (see watchSchemaOverrides).
+func (agent *ActionAgent) ReloadSchemaOverrides(ctx context.Context) error {
+	if agent.schemaOverridesFile == "" {
+		return fmt.Errorf("no schema overrides file configured")
+	}
+	schemaOverrides, err := readSchemaOverrides(agent.schemaOverridesFile)
+	if err != nil {
+		return fmt.Errorf("can't read schema overrides file %v: %v", agent.schemaOverridesFile, err)
+	}
+
+	if agent.QueryServiceControl != nil {
+		if err := agent.QueryServiceControl.ValidateSchemaOverrides(schemaOverrides); err != nil {
+			return fmt.Errorf("schema overrides in %v are invalid: %v", agent.schemaOverridesFile, err)
+		}
+	}
+
+	agent.schemaOverridesMutex.Lock()
+	agent.SchemaOverrides = schemaOverrides
+	agent.schemaOverridesMutex.Unlock()
+
+	if agent.QueryServiceControl != nil {
+		agent.QueryServiceControl.ReloadSchemaOverrides(schemaOverrides)
+	}
+
+	data, _ := json.MarshalIndent(schemaOverrides, "", "  ")
+	log.Infof("Reloaded schema overrides from %v: %s\n", agent.schemaOverridesFile, data)
+	return nil
+}
+
+// watchSchemaOverrides watches the directory containing
+// schemaOverridesFile and calls ReloadSchemaOverrides whenever the file
+// changes, so operators can tune override hints in production without
+// cycling every replica. It watches the directory rather than the file
+// itself so it keeps working across the unlink-then-rename most editors
+// and config management tools use to write the file.
+func (agent *ActionAgent) watchSchemaOverrides() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warningf("can't watch schema overrides file %v: %v", agent.schemaOverridesFile, err)
+		return
+	}
+	if err := watcher.Add(filepath.Dir(agent.schemaOverridesFile)); err != nil {
+		log.Warningf("can't watch schema overrides file %v: %v", agent.schemaOverridesFile, err)
+		watcher.Close()
+		return
+	}
+
+	go func() {
+		target := filepath.Clean(agent.schemaOverridesFile)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := agent.ReloadSchemaOverrides(agent.batchCtx); err != nil {
+					log.Warningf("failed to reload schema overrides after %v changed: %v", agent.schemaOverridesFile, err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warningf("schema overrides watcher for %v saw an error: %v", agent.schemaOverridesFile, err)
+			}
+		}
+	}()
 }
 
 // NewActionAgent creates a new ActionAgent and registers all the
@@ -135,20 +535,25 @@ func NewActionAgent(
 	mysqld := mysqlctl.NewMysqld("Dba", "App", mycnf, &dbcfgs.Dba, &dbcfgs.App.ConnectionParams, &dbcfgs.Repl)
 
 	agent = &ActionAgent{
-		QueryServiceControl: queryServiceControl,
-		HealthReporter:      health.DefaultAggregator,
-		batchCtx:            batchCtx,
-		TopoServer:          topoServer,
-		TabletAlias:         tabletAlias,
-		Mysqld:              mysqld,
-		MysqlDaemon:         mysqld,
-		DBConfigs:           dbcfgs,
-		SchemaOverrides:     schemaOverrides,
-		LockTimeout:         lockTimeout,
-		History:             history.New(historyLength),
-		lastHealthMapCount:  stats.NewInt("LastHealthMapCount"),
-		_healthy:            fmt.Errorf("healthcheck not run yet"),
-		healthStreamMap:     make(map[int]chan<- *actionnode.HealthStreamReply),
+		QueryServiceControl:       queryServiceControl,
+		batchCtx:                  batchCtx,
+		TopoServer:                topoServer,
+		TabletAlias:               tabletAlias,
+		Mysqld:                    mysqld,
+		MysqlDaemon:               mysqld,
+		DBConfigs:                 dbcfgs,
+		LockTimeout:               lockTimeout,
+		History:                   history.New(historyLength),
+		lastHealthMapCount:        stats.NewInt("LastHealthMapCount"),
+		_healthy:                  fmt.Errorf("healthcheck not run yet"),
+		healthStreamMap:           make(map[int]*healthStreamSubscriber),
+		healthStreamDroppedCounts: stats.NewCounters("HealthStreamDroppedCounts"),
+		schemaOverridesFile:       overridesFile,
+		SchemaOverrides:           schemaOverrides,
+	}
+	agent.healthReporters = agent.loadHealthReporters(*healthReportersFile)
+	if overridesFile != "" {
+		agent.watchSchemaOverrides()
 	}
 
 	// try to initialize the tablet if we have to
@@ -181,6 +586,7 @@ func NewActionAgent(
 
 	// register the RPC services from the agent
 	agent.registerQueryService()
+	registerTabletManagerRPCs(agent)
 
 	// start health check if needed
 	agent.initHeathCheck()
@@ -192,20 +598,20 @@ func NewActionAgent(
 // subset of features are supported now, but we'll add more over time.
 func NewTestActionAgent(batchCtx context.Context, ts topo.Server, tabletAlias topo.TabletAlias, port int, mysqlDaemon mysqlctl.MysqlDaemon) *ActionAgent {
 	agent := &ActionAgent{
-		QueryServiceControl: tabletserver.NewTestQueryServiceControl(),
-		HealthReporter:      health.DefaultAggregator,
-		batchCtx:            batchCtx,
-		TopoServer:          ts,
-		TabletAlias:         tabletAlias,
-		Mysqld:              nil,
-		MysqlDaemon:         mysqlDaemon,
-		DBConfigs:           nil,
-		SchemaOverrides:     nil,
-		BinlogPlayerMap:     nil,
-		History:             history.New(historyLength),
-		lastHealthMapCount:  new(stats.Int),
-		_healthy:            fmt.Errorf("healthcheck not run yet"),
-		healthStreamMap:     make(map[int]chan<- *actionnode.HealthStreamReply),
+		QueryServiceControl:       tabletserver.NewTestQueryServiceControl(),
+		batchCtx:                  batchCtx,
+		TopoServer:                ts,
+		TabletAlias:               tabletAlias,
+		Mysqld:                    nil,
+		MysqlDaemon:               mysqlDaemon,
+		DBConfigs:                 nil,
+		BinlogPlayerMap:           nil,
+		History:                   history.New(historyLength),
+		lastHealthMapCount:        new(stats.Int),
+		_healthy:                  fmt.Errorf("healthcheck not run yet"),
+		healthStreamMap:           make(map[int]*healthStreamSubscriber),
+		healthStreamDroppedCounts: stats.NewCounters("HealthStreamDroppedCounts-" + tabletAlias.String()),
+		healthReporters:           health.NewChain(health.PolicyMax),
 	}
 	if err := agent.Start(0, port, 0); err != nil {
 		panic(fmt.Errorf("agent.Start(%v) failed: %v", tabletAlias, err))
@@ -421,6 +827,108 @@ func (agent *ActionAgent) Stop() {
 	}
 }
 
+// Draining reads the _draining flag, protected by mutex. While
+// draining, the tablet must not be chosen as a reparent target.
+func (agent *ActionAgent) Draining() bool {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	return agent._draining
+}
+
+func (agent *ActionAgent) setDraining(draining bool) {
+	agent.mutex.Lock()
+	agent._draining = draining
+	agent.mutex.Unlock()
+}
+
+// PromoteSlave makes this tablet the new master as part of a planned
+// reparent operation. It is exposed as the TabletManager.PromoteSlave
+// RPC (see rpc_server.go): a reparent caller (wrangler, ...) chooses a
+// candidate and calls PromoteSlave on it directly. A draining tablet
+// refuses the call, so Drain's "ineligible as a reparent target"
+// guarantee holds for this entry point; see also
+// TabletExternallyReparented, which enforces the same guarantee for
+// reparents driven by an external tool instead of wrangler.
+func (agent *ActionAgent) PromoteSlave(ctx context.Context) error {
+	if agent.Draining() {
+		return fmt.Errorf("tablet %v is draining and cannot be promoted as a reparent target", agent.TabletAlias)
+	}
+
+	agent.actionMutex.Lock()
+	defer agent.actionMutex.Unlock()
+
+	if agent.MysqlDaemon != nil {
+		if err := agent.MysqlDaemon.StopSlave(); err != nil {
+			return fmt.Errorf("can't stop slave: %v", err)
+		}
+	}
+	return agent.refreshTablet(ctx, "PromoteSlave")
+}
+
+// TabletExternallyReparented is called on this tablet once an external
+// tool (orchestrator, a human running a manual failover, ...) has
+// already made it the new MySQL master outside of wrangler, so the
+// topology and serving graph can catch up. Like PromoteSlave, it
+// refuses a draining tablet: Drain's "ineligible as a reparent target"
+// guarantee has to hold for externally-driven reparents too, not just
+// ones wrangler initiates.
+func (agent *ActionAgent) TabletExternallyReparented(ctx context.Context) error {
+	if agent.Draining() {
+		return fmt.Errorf("tablet %v is draining and cannot accept TabletExternallyReparented", agent.TabletAlias)
+	}
+
+	agent.actionMutex.Lock()
+	defer agent.actionMutex.Unlock()
+
+	return agent.refreshTablet(ctx, "TabletExternallyReparented")
+}
+
+// Drain takes the tablet out of rotation in preparation for shutdown.
+// It stops the query service from accepting new transactions, waits up
+// to timeout for in-flight transactions to finish, marks the tablet as
+// ineligible to be chosen as a reparent target, and finally calls
+// Stop. Unlike calling Stop directly, this gives the query service and
+// the rest of the cluster a chance to notice and react before the
+// tablet's services actually go away, so a rolling restart doesn't
+// cause user-visible errors.
+//
+// It is exposed as the TabletManager.Drain RPC; see rpc_server.go.
+func (agent *ActionAgent) Drain(ctx context.Context, timeout time.Duration) error {
+	agent.actionMutex.Lock()
+	defer agent.actionMutex.Unlock()
+
+	log.Infof("Draining tablet %v, allowing up to %v for in-flight transactions to finish", agent.TabletAlias, timeout)
+	agent.setDraining(true)
+	agent.broadcastDrainingHealth()
+
+	if agent.QueryServiceControl != nil {
+		agent.QueryServiceControl.DisableNewTransactions()
+
+		drainCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := agent.QueryServiceControl.WaitForTxEmpty(drainCtx); err != nil {
+			log.Warningf("Drain: in-flight transactions on %v did not finish within %v, proceeding with shutdown anyway: %v", agent.TabletAlias, timeout, err)
+		}
+	}
+
+	agent.Stop()
+	return nil
+}
+
+// broadcastDrainingHealth sends a HealthStreamReply with Draining set,
+// so subscribers (vtgate in particular) stop routing new traffic to
+// this tablet before its services actually disappear.
+func (agent *ActionAgent) broadcastDrainingHealth() {
+	tablet := agent.Tablet()
+	if tablet == nil {
+		return
+	}
+	agent.BroadcastHealthStreamReply(&actionnode.HealthStreamReply{
+		Tablet:   tablet.Tablet,
+		Draining: true,
+	})
+}
+
 // hookExtraEnv returns the map to pass to local hooks
 func (agent *ActionAgent) hookExtraEnv() map[string]string {
 	return map[string]string{"TABLET_ALIAS": agent.TabletAlias.String()}
@@ -449,17 +957,147 @@ func (agent *ActionAgent) checkTabletMysqlPort(ctx context.Context, tablet *topo
 	return tablet
 }
 
-// BroadcastHealthStreamReply will send the HealthStreamReply to all
-// listening clients.
+// BroadcastHealthStreamReply records hsr in the replay buffer and sends
+// it to every subscriber, applying each subscriber's slow-consumer
+// policy when its channel can't immediately accept the new reply.
 func (agent *ActionAgent) BroadcastHealthStreamReply(hsr *actionnode.HealthStreamReply) {
 	agent.healthStreamMutex.Lock()
 	defer agent.healthStreamMutex.Unlock()
-	for _, c := range agent.healthStreamMap {
-		// do not block on any write
+
+	agent.healthStreamSeq++
+	agent.healthStreamBuffer = append(agent.healthStreamBuffer, healthStreamEntry{seq: agent.healthStreamSeq, reply: hsr})
+	if len(agent.healthStreamBuffer) > healthStreamHistorySize {
+		agent.healthStreamBuffer = agent.healthStreamBuffer[len(agent.healthStreamBuffer)-healthStreamHistorySize:]
+	}
+
+	for id, sub := range agent.healthStreamMap {
+		if agent.trySend(sub, hsr) {
+			continue
+		}
+		if agent.applySlowConsumerPolicy(sub, hsr) {
+			continue
+		}
+		// HealthStreamDisconnect gave up on this subscriber.
+		delete(agent.healthStreamMap, id)
+		close(sub.ch)
+	}
+}
+
+// trySend makes one non-blocking attempt to deliver hsr and clears
+// blockedSince on success.
+func (agent *ActionAgent) trySend(sub *healthStreamSubscriber, hsr *actionnode.HealthStreamReply) bool {
+	select {
+	case sub.ch <- hsr:
+		sub.blockedSince = time.Time{}
+		sub.lastEnqueued = hsr
+		return true
+	default:
+		return false
+	}
+}
+
+// applySlowConsumerPolicy is called when sub.ch is full. It returns
+// true if the subscriber should be kept around (possibly having
+// dropped a message), or false if it should be disconnected.
+func (agent *ActionAgent) applySlowConsumerPolicy(sub *healthStreamSubscriber, hsr *actionnode.HealthStreamReply) bool {
+	switch sub.policy {
+	case HealthStreamCoalesce:
+		if sub.lastEnqueued != nil && healthStreamReplyEquivalent(sub.lastEnqueued, hsr) {
+			// The tail of the queue already reflects this state, so
+			// hsr carries nothing new: just drop it and leave the
+			// queue (and its ordering) untouched.
+			agent.countDroppedHealthStreamReply(sub.id)
+			return true
+		}
+		// Not equivalent to the tail: fall back to dropping the
+		// oldest queued reply to make room, same as
+		// HealthStreamDropOldest. A channel only supports FIFO reads,
+		// so the only way to keep replies in sequence order is to
+		// always drop from the head and add at the tail; popping the
+		// head just to inspect and reinsert it would reorder replies
+		// and corrupt the since-based replay sequence.
+		select {
+		case <-sub.ch:
+		default:
+		}
 		select {
-		case c <- hsr:
+		case sub.ch <- hsr:
+			sub.lastEnqueued = hsr
 		default:
 		}
+		agent.countDroppedHealthStreamReply(sub.id)
+		return true
+	case HealthStreamDisconnect:
+		if sub.blockedSince.IsZero() {
+			sub.blockedSince = time.Now()
+		}
+		agent.countDroppedHealthStreamReply(sub.id)
+		if time.Since(sub.blockedSince) >= sub.deadline {
+			log.Warningf("health stream subscriber %v exceeded its slow-consumer deadline of %v, disconnecting", sub.id, sub.deadline)
+			return false
+		}
+		return true
+	default: // HealthStreamDropOldest
+		select {
+		case <-sub.ch:
+		default:
+		}
+		select {
+		case sub.ch <- hsr:
+			sub.lastEnqueued = hsr
+		default:
+		}
+		agent.countDroppedHealthStreamReply(sub.id)
+		return true
+	}
+}
+
+func (agent *ActionAgent) countDroppedHealthStreamReply(subscriberID int) {
+	agent.healthStreamDroppedCounts.Add(fmt.Sprintf("%v", subscriberID), 1)
+}
+
+// SubscribeHealthStream registers a new health stream subscriber and
+// returns its id, the current sequence number and its receive channel.
+// Any buffered replies with a sequence number greater than since are
+// replayed into the channel first (since 0 replays the whole buffer),
+// so a reconnecting client doesn't
+// miss a transition that happened while it was disconnected (as long
+// as it's still within the replay buffer). policy controls what
+// happens if the caller falls behind; deadline is only used by
+// HealthStreamDisconnect.
+func (agent *ActionAgent) SubscribeHealthStream(since int64, policy HealthStreamSlowConsumerPolicy, deadline time.Duration) (id int, seq int64, ch <-chan *actionnode.HealthStreamReply, err error) {
+	agent.healthStreamMutex.Lock()
+	defer agent.healthStreamMutex.Unlock()
+
+	c := make(chan *actionnode.HealthStreamReply, healthStreamHistorySize+1)
+	var lastEnqueued *actionnode.HealthStreamReply
+	for _, entry := range agent.healthStreamBuffer {
+		if entry.seq > since {
+			c <- entry.reply
+			lastEnqueued = entry.reply
+		}
+	}
+
+	agent.healthStreamIndex++
+	id = agent.healthStreamIndex
+	agent.healthStreamMap[id] = &healthStreamSubscriber{
+		id:           id,
+		ch:           c,
+		policy:       policy,
+		deadline:     deadline,
+		lastEnqueued: lastEnqueued,
+	}
+	return id, agent.healthStreamSeq, c, nil
+}
+
+// UnsubscribeHealthStream removes a subscriber previously registered
+// with SubscribeHealthStream and closes its channel.
+func (agent *ActionAgent) UnsubscribeHealthStream(id int) {
+	agent.healthStreamMutex.Lock()
+	defer agent.healthStreamMutex.Unlock()
+	if sub, ok := agent.healthStreamMap[id]; ok {
+		delete(agent.healthStreamMap, id)
+		close(sub.ch)
 	}
 }
 
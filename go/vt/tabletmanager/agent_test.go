@@ -0,0 +1,44 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// TestPromoteSlaveRejectsDrainingTablet verifies that Drain's guarantee
+// that a draining tablet can't be chosen as a reparent target is
+// actually enforced by PromoteSlave, not just advisory: the draining
+// check must short-circuit before PromoteSlave touches mysqld or the
+// topology server.
+func TestPromoteSlaveRejectsDrainingTablet(t *testing.T) {
+	agent := &ActionAgent{TabletAlias: topo.TabletAlias{Cell: "test", Uid: 1}}
+
+	if agent.Draining() {
+		t.Fatalf("freshly created agent reports Draining() == true")
+	}
+
+	agent.setDraining(true)
+	if err := agent.PromoteSlave(context.Background()); err == nil {
+		t.Fatalf("PromoteSlave on a draining tablet succeeded, want an error")
+	}
+}
+
+// TestTabletExternallyReparentedRejectsDrainingTablet verifies that
+// Drain's "ineligible as a reparent target" guarantee also holds for
+// reparents driven by an external tool rather than wrangler's
+// PromoteSlave call.
+func TestTabletExternallyReparentedRejectsDrainingTablet(t *testing.T) {
+	agent := &ActionAgent{TabletAlias: topo.TabletAlias{Cell: "test", Uid: 1}}
+
+	agent.setDraining(true)
+	if err := agent.TabletExternallyReparented(context.Background()); err == nil {
+		t.Fatalf("TabletExternallyReparented on a draining tablet succeeded, want an error")
+	}
+}
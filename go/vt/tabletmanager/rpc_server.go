@@ -0,0 +1,67 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"net/rpc"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+)
+
+// TabletManager is the RPC service that exposes the ActionAgent methods
+// Drain, PromoteSlave, TabletExternallyReparented,
+// ReloadSchemaOverrides and ReloadHealthReporters. registerTabletManagerRPCs wraps
+// one of these per agent and registers it with net/rpc, the same way
+// agent.registerQueryService registers the query service.
+//
+// net/rpc requires its registered methods to have the exact signature
+// func(*ArgsType, *ReplyType) error, so these wrappers use the agent's
+// own batchCtx rather than taking a context.Context from the caller.
+type TabletManager struct {
+	agent *ActionAgent
+}
+
+// NewTabletManager wraps agent as a TabletManager RPC service.
+func NewTabletManager(agent *ActionAgent) *TabletManager {
+	return &TabletManager{agent: agent}
+}
+
+// Drain is the RPC wrapper for ActionAgent.Drain.
+func (tm *TabletManager) Drain(args *actionnode.DrainArgs, reply *actionnode.DrainReply) error {
+	return tm.agent.Drain(tm.agent.batchCtx, args.Timeout)
+}
+
+// PromoteSlave is the RPC wrapper for ActionAgent.PromoteSlave.
+func (tm *TabletManager) PromoteSlave(args *actionnode.PromoteSlaveArgs, reply *actionnode.PromoteSlaveReply) error {
+	return tm.agent.PromoteSlave(tm.agent.batchCtx)
+}
+
+// TabletExternallyReparented is the RPC wrapper for
+// ActionAgent.TabletExternallyReparented.
+func (tm *TabletManager) TabletExternallyReparented(args *actionnode.TabletExternallyReparentedArgs, reply *actionnode.TabletExternallyReparentedReply) error {
+	return tm.agent.TabletExternallyReparented(tm.agent.batchCtx)
+}
+
+// ReloadSchemaOverrides is the RPC wrapper for
+// ActionAgent.ReloadSchemaOverrides.
+func (tm *TabletManager) ReloadSchemaOverrides(args *actionnode.ReloadSchemaOverridesArgs, reply *actionnode.ReloadSchemaOverridesReply) error {
+	return tm.agent.ReloadSchemaOverrides(tm.agent.batchCtx)
+}
+
+// ReloadHealthReporters is the RPC wrapper for
+// ActionAgent.ReloadHealthReporters.
+func (tm *TabletManager) ReloadHealthReporters(args *actionnode.ReloadHealthReportersArgs, reply *actionnode.ReloadHealthReportersReply) error {
+	return tm.agent.ReloadHealthReporters(tm.agent.batchCtx)
+}
+
+// registerTabletManagerRPCs registers agent's TabletManager service so
+// its methods are actually reachable over RPC, instead of merely being
+// defined.
+func registerTabletManagerRPCs(agent *ActionAgent) {
+	if err := rpc.Register(NewTabletManager(agent)); err != nil {
+		log.Warningf("could not register TabletManager RPC service: %v", err)
+	}
+}
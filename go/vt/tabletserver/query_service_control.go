@@ -0,0 +1,41 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tabletserver contains the query service that a tablet uses to
+// serve queries, along with the control interface tabletmanager uses to
+// drive it: starting and stopping new transactions, reloading schema
+// overrides, and the wire type for a schema override entry.
+package tabletserver
+
+import "golang.org/x/net/context"
+
+// SchemaOverride describes one table's plan-building hint, as read from
+// the -schema-override flag file and applied by ReloadSchemaOverrides.
+type SchemaOverride struct {
+	Name      string
+	PKColumns []string
+}
+
+// QueryServiceControl is the interface ActionAgent uses to control the
+// query service running alongside it.
+type QueryServiceControl interface {
+	// DisableNewTransactions stops the query service from starting any
+	// new transactions, without affecting ones already in flight. Used
+	// by ActionAgent.Drain.
+	DisableNewTransactions()
+
+	// WaitForTxEmpty blocks until every transaction in flight when
+	// DisableNewTransactions was called has finished, or ctx is done.
+	WaitForTxEmpty(ctx context.Context) error
+
+	// ValidateSchemaOverrides checks overrides against the query
+	// service's current schema without applying them, so a bad
+	// overrides file can be rejected before it's swapped in. Used by
+	// ActionAgent.ReloadSchemaOverrides.
+	ValidateSchemaOverrides(overrides []SchemaOverride) error
+
+	// ReloadSchemaOverrides applies overrides and refreshes the plan
+	// cache for the tables they affect.
+	ReloadSchemaOverrides(overrides []SchemaOverride)
+}
@@ -0,0 +1,24 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import "golang.org/x/net/context"
+
+// NewTestQueryServiceControl returns a QueryServiceControl whose
+// methods are all no-ops, for tests that construct an ActionAgent but
+// don't exercise the query service itself.
+func NewTestQueryServiceControl() QueryServiceControl {
+	return &testQueryServiceControl{}
+}
+
+type testQueryServiceControl struct{}
+
+func (testQueryServiceControl) DisableNewTransactions() {}
+
+func (testQueryServiceControl) WaitForTxEmpty(ctx context.Context) error { return nil }
+
+func (testQueryServiceControl) ValidateSchemaOverrides(overrides []SchemaOverride) error { return nil }
+
+func (testQueryServiceControl) ReloadSchemaOverrides(overrides []SchemaOverride) {}